@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	batchWriteLimit       = 25
+	batchWriteMaxRetries  = 5
+	batchWriteBaseBackoff = 200 * time.Millisecond
+	batchWriteMaxBackoff  = 10 * time.Second
+	ttlSecondsKey         = "TTL_SECONDS"
+	defaultTTLSeconds     = int64(90 * 24 * time.Hour / time.Second)
+)
+
+func getDynamoDBClient() *dynamodb.DynamoDB {
+
+	// Initialize a session that the SDK will use to load
+	// credentials from the shared credentials file ~/.aws/credentials
+	// and region from the shared configuration file ~/.aws/config.
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	// Create DynamoDB client
+	svc := dynamodb.New(sess)
+	return svc
+}
+
+// ttlSeconds returns the Unix timestamp at which a row written now should
+// expire, so DynamoDB TTL can reap old samples. The retention window is
+// configurable via TTL_SECONDS, defaulting to 90 days.
+func ttlSeconds(now time.Time) int64 {
+	window := defaultTTLSeconds
+	if raw := os.Getenv(ttlSecondsKey); raw != "" {
+		if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			window = parsed
+		} else {
+			log.Printf("invalid %s value %q, using default", ttlSecondsKey, raw)
+		}
+	}
+	return now.Unix() + window
+}
+
+// DynamoWriter buffers RoomConditions rows and flushes them with
+// BatchWriteItem in batchWriteLimit-sized chunks instead of one PutItem
+// per row, retrying whatever DynamoDB reports as UnprocessedItems.
+type DynamoWriter struct {
+	svc   dynamodbiface.DynamoDBAPI
+	items []*dynamodb.WriteRequest
+}
+
+func NewDynamoWriter(svc dynamodbiface.DynamoDBAPI) *DynamoWriter {
+	return &DynamoWriter{svc: svc}
+}
+
+func (w *DynamoWriter) Add(roomCondition *RoomConditions) error {
+	av, err := dynamodbattribute.MarshalMap(roomCondition)
+	if err != nil {
+		return fmt.Errorf("got error marshalling item: %w", err)
+	}
+
+	w.items = append(w.items, &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{Item: av},
+	})
+	return nil
+}
+
+// Flush writes every buffered item, chunked to DynamoDB's 25-item
+// BatchWriteItem limit. It honors ctx's deadline the same way getDevice
+// does, so a slow retry sequence can't run past the Lambda invocation's
+// remaining time.
+func (w *DynamoWriter) Flush(ctx context.Context) error {
+	for len(w.items) > 0 {
+		chunkSize := batchWriteLimit
+		if chunkSize > len(w.items) {
+			chunkSize = len(w.items)
+		}
+		chunk := w.items[:chunkSize]
+		w.items = w.items[chunkSize:]
+
+		if err := w.writeChunk(ctx, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *DynamoWriter) writeChunk(ctx context.Context, chunk []*dynamodb.WriteRequest) error {
+	requestItems := map[string][]*dynamodb.WriteRequest{tableName: chunk}
+
+	for attempt := 0; attempt <= batchWriteMaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(batchWriteBackoff(attempt)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		output, err := w.svc.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			log.Println("Got error calling BatchWriteItem:", err)
+			return err
+		}
+
+		unprocessed := output.UnprocessedItems[tableName]
+		if len(unprocessed) == 0 {
+			return nil
+		}
+		log.Printf("BatchWriteItem left %d unprocessed items, retrying", len(unprocessed))
+		requestItems = map[string][]*dynamodb.WriteRequest{tableName: unprocessed}
+	}
+
+	return fmt.Errorf("dynamodb: exceeded retries with %d unprocessed items", len(requestItems[tableName]))
+}
+
+func batchWriteBackoff(attempt int) time.Duration {
+	backoff := batchWriteBaseBackoff * time.Duration(1<<uint(attempt))
+	if backoff > batchWriteMaxBackoff {
+		backoff = batchWriteMaxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
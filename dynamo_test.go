@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func TestTTLSeconds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	t.Setenv(ttlSecondsKey, "")
+	if got, want := ttlSeconds(now), now.Unix()+defaultTTLSeconds; got != want {
+		t.Errorf("ttlSeconds() = %d, want %d (default)", got, want)
+	}
+
+	t.Setenv(ttlSecondsKey, "60")
+	if got, want := ttlSeconds(now), now.Unix()+60; got != want {
+		t.Errorf("ttlSeconds() = %d, want %d (overridden)", got, want)
+	}
+
+	t.Setenv(ttlSecondsKey, "not-a-number")
+	if got, want := ttlSeconds(now), now.Unix()+defaultTTLSeconds; got != want {
+		t.Errorf("ttlSeconds() = %d, want %d (falls back to default on bad input)", got, want)
+	}
+}
+
+func TestBatchWriteBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 8; attempt++ {
+		backoff := batchWriteBackoff(attempt)
+		if backoff <= 0 {
+			t.Errorf("batchWriteBackoff(%d) = %s, want > 0", attempt, backoff)
+		}
+		if backoff > batchWriteMaxBackoff {
+			t.Errorf("batchWriteBackoff(%d) = %s, want <= %s", attempt, backoff, batchWriteMaxBackoff)
+		}
+	}
+}
+
+// fakeDynamoDB is a minimal dynamodbiface.DynamoDBAPI that only implements
+// BatchWriteItemWithContext, the single method DynamoWriter relies on.
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	responses []*dynamodb.BatchWriteItemOutput
+	errs      []error
+	calls     int
+}
+
+func (f *fakeDynamoDB) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	i := f.calls
+	f.calls++
+	if i < len(f.errs) && f.errs[i] != nil {
+		return nil, f.errs[i]
+	}
+	return f.responses[i], nil
+}
+
+func TestDynamoWriterFlushRetriesUnprocessedItems(t *testing.T) {
+	item := &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{}}}
+
+	svc := &fakeDynamoDB{
+		responses: []*dynamodb.BatchWriteItemOutput{
+			{UnprocessedItems: map[string][]*dynamodb.WriteRequest{tableName: {item}}},
+			{UnprocessedItems: map[string][]*dynamodb.WriteRequest{}},
+		},
+		errs: make([]error, 2),
+	}
+
+	w := NewDynamoWriter(svc)
+	w.items = []*dynamodb.WriteRequest{item}
+
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v, want nil", err)
+	}
+	if svc.calls != 2 {
+		t.Errorf("BatchWriteItemWithContext called %d times, want 2 (one retry for unprocessed items)", svc.calls)
+	}
+}
+
+func TestDynamoWriterFlushHonorsContextCancellation(t *testing.T) {
+	item := &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{}}}
+
+	svc := &fakeDynamoDB{
+		responses: []*dynamodb.BatchWriteItemOutput{
+			{UnprocessedItems: map[string][]*dynamodb.WriteRequest{tableName: {item}}},
+		},
+		errs: make([]error, 1),
+	}
+
+	w := NewDynamoWriter(svc)
+	w.items = []*dynamodb.WriteRequest{item}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := w.Flush(ctx)
+	if err != context.Canceled {
+		t.Fatalf("Flush() = %v, want context.Canceled", err)
+	}
+}
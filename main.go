@@ -1,76 +1,40 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-type Device []struct {
-	Name              string    `json:"name"`
-	ID                string    `json:"id"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
-	MacAddress        string    `json:"mac_address"`
-	SerialNumber      string    `json:"serial_number"`
-	FirmwareVersion   string    `json:"firmware_version"`
-	TemperatureOffset int       `json:"temperature_offset"`
-	HumidityOffset    int       `json:"humidity_offset"`
-	Users             []struct {
-		ID        string `json:"id"`
-		Nickname  string `json:"nickname"`
-		Superuser bool   `json:"superuser"`
-	} `json:"users"`
-	NewestEvents struct {
-		Hu struct {
-			Val       int       `json:"val"`
-			CreatedAt time.Time `json:"created_at"`
-		} `json:"hu"`
-		Il struct {
-			Val       float64   `json:"val"`
-			CreatedAt time.Time `json:"created_at"`
-		} `json:"il"`
-		Te struct {
-			Val       float64   `json:"val"`
-			CreatedAt time.Time `json:"created_at"`
-		} `json:"te"`
-	} `json:"newest_events"`
-}
-
 type RoomConditions struct {
-	DeviceNames          string  `json:"device_names"`
-	CreatedAt            string  `json:"created_at"`
-	Humid                int     `json:"humid"`
-	HumidCreatedAt       string  `json:"humid_created_at"`
-	Illuminance          float64 `json:"illuminance"`
-	IlluminanceCreatedAt string  `json:"illuminance_created_at"`
-	Temperature          float64 `json:"temperature"`
-	TemperatureCreatedAt string  `json:"temperature_created_at"`
-}
-
-type NatureRemo struct {
-	Name                 string
-	Humid                int
-	HumidCreatedAt       time.Time
-	Temperature          float64
-	IlluminanceCreatedAt time.Time
-	Illuminance          float64
-	TemperatureCreatedAt time.Time
+	Provider             string   `json:"provider"`
+	DeviceID             string   `json:"device_id"`
+	DeviceNames          string   `json:"device_names"`
+	CreatedAt            string   `json:"created_at"`
+	TTL                  int64    `json:"ttl"`
+	Humid                *int     `json:"humid,omitempty"`
+	HumidCreatedAt       string   `json:"humid_created_at,omitempty"`
+	Illuminance          *float64 `json:"illuminance,omitempty"`
+	IlluminanceCreatedAt string   `json:"illuminance_created_at,omitempty"`
+	Temperature          *float64 `json:"temperature,omitempty"`
+	TemperatureCreatedAt string   `json:"temperature_created_at,omitempty"`
+	CO2                  *int     `json:"co2,omitempty"`
+	CO2CreatedAt         string   `json:"co2_created_at,omitempty"`
+	Pressure             *float64 `json:"pressure,omitempty"`
+	PressureCreatedAt    string   `json:"pressure_created_at,omitempty"`
+	Noise                *int     `json:"noise,omitempty"`
+	NoiseCreatedAt       string   `json:"noise_created_at,omitempty"`
 }
 
 const (
-	tableName           = "room_conditions"
-	natureRemoAccessKey = "ACCESS_KEY"
+	tableName              = "room_conditions"
+	natureRemoAccessKey    = "ACCESS_KEY"
+	netatmoClientIDKey     = "NETATMO_CLIENT_ID"
+	netatmoClientSecretKey = "NETATMO_CLIENT_SECRET"
 )
 
 type MyEvent struct {
@@ -81,133 +45,158 @@ type MyResponse struct {
 }
 
 func main() {
-	lambda.Start(roomCondition)
+	switch os.Getenv(modeKey) {
+	case serverMode:
+		if err := runServer(); err != nil {
+			log.Fatal(err)
+		}
+	case migrateMode:
+		if err := ensureTable(getDynamoDBClient()); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		lambda.Start(roomCondition)
+	}
 }
 
-func roomCondition(event MyEvent) (MyResponse, error) {
-	accessKey := os.Getenv(natureRemoAccessKey)
-	if accessKey == "" {
-		msg := "no ACCESS_KEY provided for nature remo"
-		log.Println(msg)
-		return MyResponse{ExitCode: 1}, errors.New(msg)
-	}
-	natureRemo, err := getDevice(accessKey)
+func roomCondition(ctx context.Context, event MyEvent) (MyResponse, error) {
+	providers, err := loadProviders()
 	if err != nil {
 		return MyResponse{ExitCode: 1}, err
 	}
 
-	locale, _ := time.LoadLocation("Asia/Tokyo")
-
-	roomCondition := RoomConditions{
-		DeviceNames:          natureRemo.Name,
-		CreatedAt:            time.Now().In(locale).Format(time.RFC3339),
-		Humid:                natureRemo.Humid,
-		HumidCreatedAt:       natureRemo.HumidCreatedAt.In(locale).Format(time.RFC3339),
-		Temperature:          natureRemo.Temperature,
-		TemperatureCreatedAt: natureRemo.TemperatureCreatedAt.In(locale).Format(time.RFC3339),
-		Illuminance:          natureRemo.Illuminance,
-		IlluminanceCreatedAt: natureRemo.IlluminanceCreatedAt.In(locale).Format(time.RFC3339),
+	if _, err := fetchAndPersist(ctx, providers); err != nil {
+		return MyResponse{ExitCode: 1}, err
 	}
 
-	svc := getDynamoDBClient()
+	return MyResponse{ExitCode: 0}, nil
+}
 
-	err = insertData(&roomCondition, svc)
+// fetchAndPersist fetches the latest readings from every provider and
+// writes them to DynamoDB, returning the readings it fetched. It's the
+// shared write path for both the Lambda entrypoint and the server's
+// /scrape handler, so the two don't drift independently.
+func fetchAndPersist(ctx context.Context, providers []SensorProvider) ([]Reading, error) {
+	readings, err := fetchAllReadings(ctx, providers)
 	if err != nil {
-		return MyResponse{ExitCode: 1}, err
+		return nil, err
 	}
 
-	return MyResponse{ExitCode: 0}, nil
-}
+	writer := NewDynamoWriter(getDynamoDBClient())
+	if err := bufferReadings(writer, readings); err != nil {
+		return nil, err
+	}
 
-func getDynamoDBClient() *dynamodb.DynamoDB {
+	if err := writer.Flush(ctx); err != nil {
+		return nil, err
+	}
 
-	// Initialize a session that the SDK will use to load
-	// credentials from the shared credentials file ~/.aws/credentials
-	// and region from the shared configuration file ~/.aws/config.
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	return readings, nil
+}
+
+func bufferReadings(writer *DynamoWriter, readings []Reading) error {
+	locale, _ := time.LoadLocation("Asia/Tokyo")
+	now := time.Now()
 
-	// Create DynamoDB client
-	svc := dynamodb.New(sess)
-	return svc
+	for _, reading := range readings {
+		roomCondition := toRoomConditions(reading, locale, now)
+		if err := writer.Add(&roomCondition); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func insertData(roomCondition *RoomConditions, svc *dynamodb.DynamoDB) error {
-	av, err := dynamodbattribute.MarshalMap(roomCondition)
-	if err != nil {
-		msg := "Got error marshalling item. %s"
-		log.Println(err)
-		return errors.New(msg)
+// loadProviders builds the list of enabled SensorProviders from the
+// environment. A provider is enabled simply by setting its credentials,
+// so households with mixed hardware can run both at once.
+func loadProviders() ([]SensorProvider, error) {
+	var providers []SensorProvider
+
+	if accessKey := os.Getenv(natureRemoAccessKey); accessKey != "" {
+		providers = append(providers, NewNatureRemoProvider(accessKey))
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(tableName),
+	if clientID, clientSecret := os.Getenv(netatmoClientIDKey), os.Getenv(netatmoClientSecretKey); clientID != "" && clientSecret != "" {
+		providers = append(providers, NewNetatmoProvider(clientID, clientSecret))
 	}
 
-	_, err = svc.PutItem(input)
-	if err != nil {
-		log.Println("Got error calling PutItem: %v", av)
-		return err
+	if len(providers) == 0 {
+		msg := "no sensor providers configured"
+		log.Println(msg)
+		return nil, errors.New(msg)
 	}
+	return providers, nil
 }
 
-func getDevice(accessKey string) (NatureRemo, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://api.nature.global/1/devices", nil)
-	if err != nil {
-		msg := "cannot get new request client"
-		log.Println(err)
-		return NatureRemo{}, errors.New(msg)
+// fetchAllReadings fans out to every provider concurrently and merges the
+// results. A provider failing does not stop the others; the call only
+// fails outright if none of them returned anything.
+func fetchAllReadings(ctx context.Context, providers []SensorProvider) ([]Reading, error) {
+	type result struct {
+		readings []Reading
+		err      error
 	}
-	req.Header.Add("accept", "application/json")
 
-	req.Header.Add("Authorization", "Bearer "+accessKey)
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println(err)
-		msg := "cannot get response from remo"
-		return NatureRemo{}, errors.New(msg)
+	results := make(chan result, len(providers))
+	for _, provider := range providers {
+		go func(provider SensorProvider) {
+			readings, err := provider.FetchReadings(ctx)
+			if err != nil {
+				log.Printf("%s: %v", provider.Name(), err)
+			}
+			results <- result{readings: readings, err: err}
+		}(provider)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println("nature remo returns %d. ", resp.StatusCode)
-		msg := "invalid status code"
-		return NatureRemo{}, errors.New(msg)
+	var all []Reading
+	var firstErr error
+	for range providers {
+		r := <-results
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		all = append(all, r.readings...)
 	}
-	var data Device
 
-	byteArr, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println(err)
-		msg := "failed to read response body"
-		return NatureRemo{}, errors.New(msg)
+	if len(all) == 0 && firstErr != nil {
+		return nil, firstErr
 	}
-	// TODO if get err of unauthorised
-	err = json.Unmarshal(byteArr, &data)
-	if err != nil {
-		log.Println(err)
-		msg := "failed to unmarshal json"
-		return NatureRemo{}, errors.New(msg)
-	}
-
-	events := data[0].NewestEvents
-	name := data[0].Name
-	humid := events.Hu.Val
-	humidCreatedAt := events.Hu.CreatedAt.Local()
-	illuminance := events.Il.Val
-	illuminanceCreatedAt := events.Il.CreatedAt.Local()
-	temperature := events.Te.Val
-	temperatureCreatedAt := events.Te.CreatedAt.Local()
-	return NatureRemo{
-		Name:                 name,
-		Humid:                humid,
-		HumidCreatedAt:       humidCreatedAt,
-		Illuminance:          illuminance,
-		IlluminanceCreatedAt: illuminanceCreatedAt,
-		Temperature:          temperature,
-		TemperatureCreatedAt: temperatureCreatedAt,
-	}, nil
+	return all, nil
+}
+
+func toRoomConditions(reading Reading, locale *time.Location, now time.Time) RoomConditions {
+	rc := RoomConditions{
+		Provider:    reading.Provider,
+		DeviceID:    reading.DeviceID,
+		DeviceNames: reading.DeviceName,
+		CreatedAt:   now.In(locale).Format(time.RFC3339),
+		TTL:         ttlSeconds(now),
+	}
+
+	if reading.Temperature != nil {
+		rc.Temperature = reading.Temperature
+		rc.TemperatureCreatedAt = reading.TemperatureAt.In(locale).Format(time.RFC3339)
+	}
+	if reading.Humidity != nil {
+		rc.Humid = reading.Humidity
+		rc.HumidCreatedAt = reading.HumidityAt.In(locale).Format(time.RFC3339)
+	}
+	if reading.Illuminance != nil {
+		rc.Illuminance = reading.Illuminance
+		rc.IlluminanceCreatedAt = reading.IlluminanceAt.In(locale).Format(time.RFC3339)
+	}
+	if reading.CO2 != nil {
+		rc.CO2 = reading.CO2
+		rc.CO2CreatedAt = reading.CO2At.In(locale).Format(time.RFC3339)
+	}
+	if reading.Pressure != nil {
+		rc.Pressure = reading.Pressure
+		rc.PressureCreatedAt = reading.PressureAt.In(locale).Format(time.RFC3339)
+	}
+	if reading.Noise != nil {
+		rc.Noise = reading.Noise
+		rc.NoiseCreatedAt = reading.NoiseAt.In(locale).Format(time.RFC3339)
+	}
+	return rc
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const migrateMode = "migrate"
+
+// ensureTable creates the room_conditions table with the composite key
+// this package relies on (device_id partition key, created_at sort key)
+// if it doesn't already exist, and makes sure TTL expiry is turned on for
+// the ttl attribute. Run once per environment via MODE=migrate; it is not
+// meant to run on every invocation.
+func ensureTable(svc *dynamodb.DynamoDB) error {
+	_, err := svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err == nil {
+		log.Printf("table %s already exists", tableName)
+		return enableTTL(svc)
+	}
+
+	_, err = svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("device_id"), AttributeType: aws.String("S")},
+			{AttributeName: aws.String("created_at"), AttributeType: aws.String("S")},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("device_id"), KeyType: aws.String("HASH")},
+			{AttributeName: aws.String("created_at"), KeyType: aws.String("RANGE")},
+		},
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	})
+	if err != nil {
+		log.Println("Got error calling CreateTable:", err)
+		return err
+	}
+
+	if err := svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(tableName)}); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return enableTTL(svc)
+}
+
+// enableTTL turns on TTL expiry for the ttl attribute. It first checks the
+// current status since UpdateTimeToLive errors if TTL is already enabled
+// on that attribute, and ensureTable calls this on every run.
+func enableTTL(svc *dynamodb.DynamoDB) error {
+	desc, err := svc.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		log.Println("Got error calling DescribeTimeToLive:", err)
+		return err
+	}
+	if ttl := desc.TimeToLiveDescription; ttl != nil &&
+		aws.StringValue(ttl.AttributeName) == "ttl" &&
+		aws.StringValue(ttl.TimeToLiveStatus) == dynamodb.TimeToLiveStatusEnabled {
+		log.Printf("TTL already enabled on %s", tableName)
+		return nil
+	}
+
+	_, err = svc.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String("ttl"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		log.Println("Got error calling UpdateTimeToLive:", err)
+		return err
+	}
+	return nil
+}
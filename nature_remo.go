@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Device []struct {
+	Name              string    `json:"name"`
+	ID                string    `json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	MacAddress        string    `json:"mac_address"`
+	SerialNumber      string    `json:"serial_number"`
+	FirmwareVersion   string    `json:"firmware_version"`
+	TemperatureOffset int       `json:"temperature_offset"`
+	HumidityOffset    int       `json:"humidity_offset"`
+	Users             []struct {
+		ID        string `json:"id"`
+		Nickname  string `json:"nickname"`
+		Superuser bool   `json:"superuser"`
+	} `json:"users"`
+	NewestEvents struct {
+		Hu struct {
+			Val       int       `json:"val"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"hu"`
+		Il struct {
+			Val       float64   `json:"val"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"il"`
+		Te struct {
+			Val       float64   `json:"val"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"te"`
+	} `json:"newest_events"`
+}
+
+type NatureRemo struct {
+	ID                   string
+	Name                 string
+	Humid                int
+	HumidCreatedAt       time.Time
+	Temperature          float64
+	IlluminanceCreatedAt time.Time
+	Illuminance          float64
+	TemperatureCreatedAt time.Time
+}
+
+// UnauthorizedError means Nature Remo rejected the access key (HTTP 401).
+// Retrying will not help, so callers should treat it as terminal.
+type UnauthorizedError struct {
+	StatusCode int
+}
+
+func (e *UnauthorizedError) Error() string {
+	return fmt.Sprintf("nature remo: unauthorized (status %d)", e.StatusCode)
+}
+
+const (
+	natureRemoMaxRetries  = 5
+	natureRemoBaseBackoff = 500 * time.Millisecond
+	natureRemoMaxBackoff  = 30 * time.Second
+)
+
+// NatureRemoProvider is the SensorProvider for Nature Remo devices,
+// fetched from https://api.nature.global/1/devices.
+type NatureRemoProvider struct {
+	AccessKey string
+}
+
+func NewNatureRemoProvider(accessKey string) *NatureRemoProvider {
+	return &NatureRemoProvider{AccessKey: accessKey}
+}
+
+func (p *NatureRemoProvider) Name() string {
+	return "nature_remo"
+}
+
+func (p *NatureRemoProvider) FetchReadings(ctx context.Context) ([]Reading, error) {
+	devices, err := getDevice(ctx, p.AccessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	readings := make([]Reading, 0, len(devices))
+	for _, device := range devices {
+		reading := Reading{Provider: p.Name(), DeviceID: device.ID, DeviceName: device.Name}
+		if !device.TemperatureCreatedAt.IsZero() {
+			temperature := device.Temperature
+			reading.Temperature, reading.TemperatureAt = &temperature, device.TemperatureCreatedAt
+		}
+		if !device.HumidCreatedAt.IsZero() {
+			humid := device.Humid
+			reading.Humidity, reading.HumidityAt = &humid, device.HumidCreatedAt
+		}
+		if !device.IlluminanceCreatedAt.IsZero() {
+			illuminance := device.Illuminance
+			reading.Illuminance, reading.IlluminanceAt = &illuminance, device.IlluminanceCreatedAt
+		}
+		readings = append(readings, reading)
+	}
+	return readings, nil
+}
+
+// getDevice fetches the device list, honoring ctx's deadline (Lambda's
+// remaining execution time, or the caller's own timeout in server mode)
+// and retrying with exponential backoff plus jitter on 429/5xx, reading
+// Retry-After and X-Rate-Limit-Reset when Nature Remo sends them. A 401
+// is returned immediately as an *UnauthorizedError since retrying a bad
+// access key never helps.
+func getDevice(ctx context.Context, accessKey string) ([]NatureRemo, error) {
+	client := &http.Client{}
+
+	var lastErr error
+	var wait time.Duration
+	for attempt := 0; attempt <= natureRemoMaxRetries; attempt++ {
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", "https://api.nature.global/1/devices", nil)
+		if err != nil {
+			msg := "cannot get new request client"
+			log.Println(err)
+			return nil, errors.New(msg)
+		}
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("Authorization", "Bearer "+accessKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Println(err)
+			lastErr = err
+			wait = natureRemoBackoff(attempt)
+			continue
+		}
+
+		if remaining := resp.Header.Get("X-Rate-Limit-Remaining"); remaining != "" {
+			log.Printf("nature remo rate limit remaining: %s", remaining)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			return nil, &UnauthorizedError{StatusCode: resp.StatusCode}
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = fmt.Errorf("nature remo returned status %d", resp.StatusCode)
+			wait = natureRemoRetryAfter(resp.Header, attempt)
+			resp.Body.Close()
+			log.Printf("%v, retrying in %s", lastErr, wait)
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			log.Printf("nature remo returns %d", resp.StatusCode)
+			msg := "invalid status code"
+			return nil, errors.New(msg)
+		}
+
+		natureRemos, err := decodeDevices(resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		return natureRemos, nil
+	}
+
+	return nil, fmt.Errorf("nature remo: exceeded retries: %w", lastErr)
+}
+
+func decodeDevices(resp *http.Response) ([]NatureRemo, error) {
+	var data Device
+
+	byteArr, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Println(err)
+		msg := "failed to read response body"
+		return nil, errors.New(msg)
+	}
+	err = json.Unmarshal(byteArr, &data)
+	if err != nil {
+		log.Println(err)
+		msg := "failed to unmarshal json"
+		return nil, errors.New(msg)
+	}
+
+	natureRemos := make([]NatureRemo, 0, len(data))
+	for _, device := range data {
+		events := device.NewestEvents
+		natureRemos = append(natureRemos, NatureRemo{
+			ID:                   device.ID,
+			Name:                 device.Name,
+			Humid:                events.Hu.Val,
+			HumidCreatedAt:       events.Hu.CreatedAt.Local(),
+			Illuminance:          events.Il.Val,
+			IlluminanceCreatedAt: events.Il.CreatedAt.Local(),
+			Temperature:          events.Te.Val,
+			TemperatureCreatedAt: events.Te.CreatedAt.Local(),
+		})
+	}
+	return natureRemos, nil
+}
+
+// natureRemoRetryAfter honors Retry-After and X-Rate-Limit-Reset when
+// Nature Remo sends them, falling back to exponential backoff with
+// jitter otherwise.
+func natureRemoRetryAfter(h http.Header, attempt int) time.Duration {
+	if ra := h.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(ra); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d
+			}
+		}
+	}
+	if reset := h.Get("X-Rate-Limit-Reset"); reset != "" {
+		if epoch, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			if d := time.Until(time.Unix(epoch, 0)); d > 0 {
+				return d
+			}
+		}
+	}
+	return natureRemoBackoff(attempt)
+}
+
+func natureRemoBackoff(attempt int) time.Duration {
+	backoff := natureRemoBaseBackoff * time.Duration(1<<uint(attempt))
+	if backoff > natureRemoMaxBackoff {
+		backoff = natureRemoMaxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
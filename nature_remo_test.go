@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNatureRemoBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 8; attempt++ {
+		backoff := natureRemoBackoff(attempt)
+		if backoff <= 0 {
+			t.Errorf("natureRemoBackoff(%d) = %s, want > 0", attempt, backoff)
+		}
+		if backoff > natureRemoMaxBackoff {
+			t.Errorf("natureRemoBackoff(%d) = %s, want <= %s", attempt, backoff, natureRemoMaxBackoff)
+		}
+	}
+}
+
+func TestNatureRemoRetryAfterSeconds(t *testing.T) {
+	h := http.Header{}
+	h.Set("Retry-After", "5")
+
+	if got, want := natureRemoRetryAfter(h, 0), 5*time.Second; got != want {
+		t.Errorf("natureRemoRetryAfter() = %s, want %s", got, want)
+	}
+}
+
+func TestNatureRemoRetryAfterHTTPDate(t *testing.T) {
+	future := time.Now().Add(10 * time.Second).UTC()
+	h := http.Header{}
+	h.Set("Retry-After", future.Format(http.TimeFormat))
+
+	got := natureRemoRetryAfter(h, 0)
+	if got <= 0 || got > 10*time.Second {
+		t.Errorf("natureRemoRetryAfter() = %s, want roughly 10s", got)
+	}
+}
+
+func TestNatureRemoRetryAfterRateLimitReset(t *testing.T) {
+	reset := time.Now().Add(5 * time.Second).Unix()
+	h := http.Header{}
+	h.Set("X-Rate-Limit-Reset", strconv.FormatInt(reset, 10))
+
+	got := natureRemoRetryAfter(h, 0)
+	if got <= 0 || got > 5*time.Second {
+		t.Errorf("natureRemoRetryAfter() = %s, want roughly 5s", got)
+	}
+}
+
+func TestNatureRemoRetryAfterFallsBackToBackoff(t *testing.T) {
+	got := natureRemoRetryAfter(http.Header{}, 3)
+	if got <= 0 || got > natureRemoMaxBackoff {
+		t.Errorf("natureRemoRetryAfter() = %s, want a natureRemoBackoff(3)-shaped value", got)
+	}
+}
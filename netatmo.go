@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const netatmoStationsDataURL = "https://api.netatmo.com/api/getstationsdata"
+
+type netatmoStationsResponse struct {
+	Body struct {
+		Devices []netatmoDevice `json:"devices"`
+	} `json:"body"`
+}
+
+type netatmoDevice struct {
+	ID            string               `json:"_id"`
+	ModuleName    string               `json:"module_name"`
+	DashboardData netatmoDashboardData `json:"dashboard_data"`
+	Modules       []netatmoModule      `json:"modules"`
+}
+
+type netatmoModule struct {
+	ID            string               `json:"_id"`
+	ModuleName    string               `json:"module_name"`
+	DashboardData netatmoDashboardData `json:"dashboard_data"`
+}
+
+type netatmoDashboardData struct {
+	Temperature *float64 `json:"Temperature"`
+	Humidity    *int     `json:"Humidity"`
+	CO2         *int     `json:"CO2"`
+	Pressure    *float64 `json:"Pressure"`
+	Noise       *int     `json:"Noise"`
+	TimeUTC     int64    `json:"time_utc"`
+}
+
+// NetatmoProvider is the SensorProvider for a Netatmo Weather Station,
+// fetched from https://api.netatmo.com/api/getstationsdata. It walks the
+// station + module tree and emits one Reading per module.
+type NetatmoProvider struct {
+	ClientID     string
+	ClientSecret string
+	httpClient   *http.Client
+}
+
+func NewNetatmoProvider(clientID, clientSecret string) *NetatmoProvider {
+	conf := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     "https://api.netatmo.com/oauth2/token",
+		Scopes:       []string{"read_station"},
+	}
+	return &NetatmoProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		httpClient:   conf.Client(context.Background()),
+	}
+}
+
+func (p *NetatmoProvider) Name() string {
+	return "netatmo"
+}
+
+func (p *NetatmoProvider) FetchReadings(ctx context.Context) ([]Reading, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", netatmoStationsDataURL, nil)
+	if err != nil {
+		msg := "cannot get new request client"
+		log.Println(err)
+		return nil, errors.New(msg)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		log.Println(err)
+		msg := "cannot get response from netatmo"
+		return nil, errors.New(msg)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("netatmo returns %d. ", resp.StatusCode)
+		msg := "invalid status code"
+		return nil, errors.New(msg)
+	}
+
+	var data netatmoStationsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		log.Println(err)
+		msg := "failed to unmarshal json"
+		return nil, errors.New(msg)
+	}
+
+	var readings []Reading
+	for _, station := range data.Body.Devices {
+		readings = append(readings, p.toReading(station.ID, station.ModuleName, station.DashboardData))
+		for _, module := range station.Modules {
+			readings = append(readings, p.toReading(module.ID, module.ModuleName, module.DashboardData))
+		}
+	}
+	return readings, nil
+}
+
+func (p *NetatmoProvider) toReading(deviceID, deviceName string, d netatmoDashboardData) Reading {
+	at := time.Unix(d.TimeUTC, 0)
+	return Reading{
+		Provider:      p.Name(),
+		DeviceID:      deviceID,
+		DeviceName:    deviceName,
+		Temperature:   d.Temperature,
+		TemperatureAt: at,
+		Humidity:      d.Humidity,
+		HumidityAt:    at,
+		CO2:           d.CO2,
+		CO2At:         at,
+		Pressure:      d.Pressure,
+		PressureAt:    at,
+		Noise:         d.Noise,
+		NoiseAt:       at,
+	}
+}
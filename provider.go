@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Reading is a normalized sensor sample, independent of which device or
+// vendor API produced it. Fields the source device does not report are
+// left nil so callers can tell "zero" from "not measured".
+type Reading struct {
+	Provider   string
+	DeviceID   string
+	DeviceName string
+
+	Temperature   *float64
+	TemperatureAt time.Time
+
+	Humidity   *int
+	HumidityAt time.Time
+
+	Illuminance   *float64
+	IlluminanceAt time.Time
+
+	CO2   *int
+	CO2At time.Time
+
+	Pressure   *float64
+	PressureAt time.Time
+
+	Noise   *int
+	NoiseAt time.Time
+}
+
+// SensorProvider fetches the latest readings from one family of hardware
+// (Nature Remo, Netatmo, ...). Implementations are responsible for their
+// own auth and API quirks; callers only see normalized Readings.
+type SensorProvider interface {
+	Name() string
+	FetchReadings(ctx context.Context) ([]Reading, error)
+}
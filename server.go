@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	modeKey               = "MODE"
+	serverMode            = "server"
+	listenAddrKey         = "LISTEN_ADDR"
+	defaultAddr           = ":8080"
+	scrapeIntervalKey     = "SCRAPE_INTERVAL_SECONDS"
+	defaultScrapeInterval = 60 * time.Second
+)
+
+// readingsCache holds the last successful fetch so /metrics can serve
+// Prometheus's scrape cadence without hitting Nature Remo/Netatmo (and
+// their rate limits) on every request.
+type readingsCache struct {
+	mu       sync.RWMutex
+	readings []Reading
+}
+
+func (c *readingsCache) set(readings []Reading) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readings = readings
+}
+
+func (c *readingsCache) get() []Reading {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.readings
+}
+
+// runServer starts a local HTTP server exposing /healthz, /scrape and
+// /metrics, so the binary can run standalone next to the sensors instead
+// of behind Lambda, and be scraped straight from Grafana/Prometheus.
+func runServer() error {
+	providers, err := loadProviders()
+	if err != nil {
+		return err
+	}
+
+	addr := os.Getenv(listenAddrKey)
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	cache := &readingsCache{}
+	go refreshCacheLoop(cache, providers)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/scrape", handleScrape(providers, cache))
+	mux.HandleFunc("/metrics", handleMetrics(cache))
+
+	log.Printf("listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// refreshCacheLoop keeps the cache warm on its own interval, independent
+// of how often /metrics is actually scraped.
+func refreshCacheLoop(cache *readingsCache, providers []SensorProvider) {
+	interval := defaultScrapeInterval
+	if raw := os.Getenv(scrapeIntervalKey); raw != "" {
+		if secs, err := strconv.Atoi(raw); err == nil {
+			interval = time.Duration(secs) * time.Second
+		} else {
+			log.Printf("invalid %s value %q, using default", scrapeIntervalKey, raw)
+		}
+	}
+
+	for {
+		readings, err := fetchAllReadings(context.Background(), providers)
+		if err != nil {
+			log.Println(err)
+		} else {
+			cache.set(readings)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// handleScrape triggers the same fetch-and-persist path as the Lambda
+// entrypoint against the given providers, refreshes the cache that
+// /metrics reads from, and returns the readings as JSON.
+func handleScrape(providers []SensorProvider, cache *readingsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readings, err := fetchAndPersist(r.Context(), providers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cache.set(readings)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(readings); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// handleMetrics exposes the cached readings in Prometheus exposition
+// format, labelled by device name. It never calls out to the sensor
+// providers itself, so a Prometheus scrape cadence can't blow through
+// Nature Remo's rate limits.
+func handleMetrics(cache *readingsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readings := cache.get()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		var b strings.Builder
+		writeGauge(&b, "room_condition_temperature_celsius", "Temperature reported by the device.", readings, func(r Reading) *float64 { return r.Temperature })
+		writeGauge(&b, "room_condition_humidity_percent", "Relative humidity reported by the device.", readings, func(r Reading) *float64 {
+			if r.Humidity == nil {
+				return nil
+			}
+			v := float64(*r.Humidity)
+			return &v
+		})
+		writeGauge(&b, "room_condition_illuminance_lux", "Illuminance reported by the device.", readings, func(r Reading) *float64 { return r.Illuminance })
+
+		w.Write([]byte(b.String()))
+	}
+}
+
+func writeGauge(b *strings.Builder, name, help string, readings []Reading, value func(Reading) *float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, reading := range readings {
+		v := value(reading)
+		if v == nil {
+			continue
+		}
+		fmt.Fprintf(b, "%s{provider=%q,device=%q} %v\n", name, reading.Provider, reading.DeviceName, *v)
+	}
+}